@@ -0,0 +1,130 @@
+package ntp
+
+import (
+	"bytes"
+	"testing"
+)
+
+type mapKeyStore map[uint32]struct {
+	key  []byte
+	algo HashAlgo
+}
+
+func (m mapKeyStore) Lookup(keyID uint32) ([]byte, HashAlgo, bool) {
+	e, ok := m[keyID]
+	return e.key, e.algo, ok
+}
+
+func TestMsgPackUnpackAuth(t *testing.T) {
+	ks := mapKeyStore{
+		1: {key: []byte("shared-secret"), algo: HashMD5},
+		2: {key: []byte("other-secret"), algo: HashSHA1},
+	}
+
+	for _, algo := range []HashAlgo{HashMD5, HashSHA1} {
+		keyID := uint32(1)
+		if algo == HashSHA1 {
+			keyID = 2
+		}
+		m := &Msg{Header: MsgHeader{Version: 4, Mode: ClientMode}, KeyID: keyID}
+
+		buf := make([]byte, HeaderSize+4+algo.Size())
+		packed, err := m.Pack(buf, ks)
+		if err != nil {
+			t.Fatalf("Pack: %s", err)
+		}
+
+		var got Msg
+		if err := got.Unpack(packed, ks); err != nil {
+			t.Fatalf("Unpack: %s", err)
+		}
+		if got.Header.Mode != ClientMode {
+			t.Errorf("Mode = %d, expected %d", got.Header.Mode, ClientMode)
+		}
+		if got.KeyID != keyID {
+			t.Errorf("KeyID = %d, expected %d", got.KeyID, keyID)
+		}
+	}
+}
+
+func TestMsgUnpackAuthFailed(t *testing.T) {
+	ks := mapKeyStore{1: {key: []byte("shared-secret"), algo: HashMD5}}
+
+	m := &Msg{Header: MsgHeader{Version: 4, Mode: ClientMode}}
+	buf := make([]byte, HeaderSize+4+HashMD5.Size())
+	packed, err := m.Pack(buf, m.Sign(1, []byte("shared-secret")))
+	if err != nil {
+		t.Fatalf("Pack: %s", err)
+	}
+
+	// A different key for the same ID should fail verification.
+	wrongKS := mapKeyStore{1: {key: []byte("wrong-secret"), algo: HashMD5}}
+	var got Msg
+	if err := got.Unpack(packed, wrongKS); err != ErrAuthFailed {
+		t.Errorf("Unpack with wrong key: got %v, expected %v", err, ErrAuthFailed)
+	}
+
+	// An unknown key ID should also fail verification.
+	var got2 Msg
+	if err := got2.Unpack(packed, mapKeyStore{}); err != ErrAuthFailed {
+		t.Errorf("Unpack with unknown key: got %v, expected %v", err, ErrAuthFailed)
+	}
+
+	// Verifying with the right key should succeed.
+	var got3 Msg
+	if err := got3.Unpack(packed, ks); err != nil {
+		t.Errorf("Unpack with correct key: got %v, expected nil", err)
+	}
+}
+
+func TestMsgUnpackExtensionFieldsOnlyWithKeyStore(t *testing.T) {
+	// A message secured only via extension fields (e.g. NTS, or simply
+	// unauthenticated) carries no KeyID/Dgst trailer at all. Configuring a
+	// KeyStore must not force Unpack to treat that as an auth failure.
+	ks := mapKeyStore{1: {key: []byte("shared-secret"), algo: HashMD5}}
+
+	m := &Msg{
+		Header:          MsgHeader{Version: 4, Mode: ClientMode},
+		ExtensionFields: []ExtensionField{NewNTSCookie([]byte{1, 2, 3})},
+	}
+	buf := make([]byte, HeaderSize+64)
+	packed, err := m.Pack(buf, nil)
+	if err != nil {
+		t.Fatalf("Pack: %s", err)
+	}
+
+	var got Msg
+	if err := got.Unpack(packed, ks); err != nil {
+		t.Fatalf("Unpack with KeyStore configured but no trailer present: got %v, expected nil", err)
+	}
+	field, ok := got.ExtensionFields[0].(*NTSCookie)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSCookie", got.ExtensionFields[0])
+	}
+	if !bytes.Equal(field.Cookie(), []byte{1, 2, 3}) {
+		t.Errorf("Cookie() = %x, expected 010203", field.Cookie())
+	}
+	if got.KeyID != 0 {
+		t.Errorf("KeyID = %d, expected 0 (no trailer was present)", got.KeyID)
+	}
+}
+
+func TestMsgPackUnpackUnauthenticated(t *testing.T) {
+	m := &Msg{Header: MsgHeader{Version: 4, Mode: ClientMode}}
+	buf := make([]byte, HeaderSize)
+	packed, err := m.Pack(buf, nil)
+	if err != nil {
+		t.Fatalf("Pack: %s", err)
+	}
+	if len(packed) != HeaderSize {
+		t.Errorf("len(packed) = %d, expected %d", len(packed), HeaderSize)
+	}
+
+	var got Msg
+	if err := got.Unpack(packed, nil); err != nil {
+		t.Fatalf("Unpack: %s", err)
+	}
+	if got.Header.Mode != ClientMode {
+		t.Errorf("Mode = %d, expected %d", got.Header.Mode, ClientMode)
+	}
+}
@@ -0,0 +1,170 @@
+package ntp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultPort is the standard NTP service port (RFC 5905 section 4).
+const DefaultPort = 123
+
+// ErrReplayOrLoop is returned by Query when a response's Org timestamp
+// doesn't match the Xmt timestamp of the request it's supposedly answering.
+// This is the loop/replay detection the random low-order bits of
+// NewTimestampFromTime exist for (see the SNTP comment above it).
+var ErrReplayOrLoop = errors.New("ntp: response Org does not match request Xmt")
+
+// Client queries NTP servers for the current time over UDP.
+type Client struct {
+	// Version is the NTP protocol version advertised in outgoing
+	// requests. It defaults to 4 if zero.
+	Version uint8
+
+	// Timeout bounds how long Query waits for a reply, when ctx doesn't
+	// already carry a deadline. It defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// Response is the decoded and analyzed result of a successful Query.
+type Response struct {
+	// Time is the client's best estimate of the current true time,
+	// i.e. the local receive time corrected by ClockOffset.
+	Time time.Time
+
+	// ClockOffset is the estimated difference between the server's clock
+	// and the local clock (positive means the local clock is behind).
+	ClockOffset time.Duration
+
+	// RoundTripDelay is the estimated network round-trip delay.
+	RoundTripDelay time.Duration
+
+	Leap           uint8
+	Stratum        uint8
+	RefID          [4]byte
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	Precision      time.Duration
+}
+
+// Query sends a single NTP client-mode request to server (a "host" or
+// "host:port", defaulting to DefaultPort) and returns the decoded response.
+func (c *Client) Query(ctx context.Context, server string) (*Response, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, strconv.Itoa(DefaultPort))
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	version := c.Version
+	if version == 0 {
+		version = 4
+	}
+
+	xmt, err := NewTimestampFromTime(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	req := Msg{
+		Header: MsgHeader{
+			Version: version,
+			Mode:    ClientMode,
+			Xmt:     xmt,
+		},
+	}
+
+	// A plain, unauthenticated client-mode request is just the fixed
+	// 48-byte header: no extension fields, no KeyID/Dgst trailer.
+	buf := make([]byte, HeaderSize)
+	if _, err := req.Header.Pack(buf); err != nil {
+		return nil, err
+	}
+	// MsgHeader.Pack fills only the first 12 bytes; the transmit timestamp,
+	// which the server echoes back as Org, goes in bytes 40-47.
+	packTimestamp(req.Header.Xmt, buf[40:48])
+	if _, err := conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	// A response may carry extension fields (e.g. NTS) past the fixed
+	// header, so size the read buffer generously rather than truncating it.
+	rbuf := make([]byte, 2048)
+	n, err := conn.Read(rbuf)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := NewTimestampFromTime(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var resp Msg
+	if err := resp.Unpack(rbuf[:n], nil); err != nil {
+		return nil, err
+	}
+	// Compare Seconds/Fraction only: Org came off the wire via
+	// unpackTimestamp, which never sets Era, while Xmt was built by
+	// NewTimestampFromTime, which does. Comparing the full Timestamp would
+	// spuriously fail every request in era 1 and beyond (2036-02-07 on).
+	if resp.Header.Org.Seconds != req.Header.Xmt.Seconds || resp.Header.Org.Fraction != req.Header.Xmt.Fraction {
+		return nil, ErrReplayOrLoop
+	}
+
+	offset, rtt := offsetAndRoundTrip(req.Header.Xmt, resp.Header.Rec, resp.Header.Xmt, dst)
+
+	return &Response{
+		Time:           TimeFromTimestamp(dst).Add(offset),
+		ClockOffset:    offset,
+		RoundTripDelay: rtt,
+		Leap:           resp.Header.Leap,
+		Stratum:        resp.Header.Stratum,
+		RefID:          resp.Header.RefID,
+		RootDelay:      durationFromShort(resp.Header.RootDelay),
+		RootDispersion: durationFromShort(resp.Header.RootDisp),
+		Precision:      time.Duration(resp.Header.Precision.Float() * float64(time.Second)),
+	}, nil
+}
+
+// offsetAndRoundTrip computes the classic NTP offset and round-trip delay
+// from the four exchange timestamps, per RFC 5905 section 8:
+//
+//	offset = ((T2-T1)+(T3-T4))/2
+//	delay  = (T4-T1)-(T3-T2)
+func offsetAndRoundTrip(xmt, rec, xmt2, dst Timestamp) (offset, roundTrip time.Duration) {
+	t1 := TimeFromTimestamp(xmt)
+	t2 := TimeFromTimestamp(rec)
+	t3 := TimeFromTimestamp(xmt2)
+	t4 := TimeFromTimestamp(dst)
+
+	offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	roundTrip = t4.Sub(t1) - t3.Sub(t2)
+	return offset, roundTrip
+}
+
+func durationFromShort(s Short) time.Duration {
+	return time.Duration(s.Seconds)*time.Second + time.Duration(int64(s.Fraction)*1e9>>16)
+}
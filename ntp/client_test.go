@@ -0,0 +1,41 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+)
+
+func mustTimestamp(t *testing.T, when time.Time) Timestamp {
+	t.Helper()
+	ts, err := NewTimestampFromTime(when)
+	if err != nil {
+		t.Fatalf("NewTimestampFromTime(%s): %s", when, err)
+	}
+	return ts
+}
+
+func TestOffsetAndRoundTrip(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := mustTimestamp(t, base)
+	t2 := mustTimestamp(t, base.Add(1*time.Second))
+	t3 := mustTimestamp(t, base.Add(1500*time.Millisecond))
+	t4 := mustTimestamp(t, base.Add(2500*time.Millisecond))
+
+	offset, rtt := offsetAndRoundTrip(t1, t2, t3, t4)
+	if offset != 0 {
+		t.Errorf("offset = %s, expected 0", offset)
+	}
+	if rtt != 2*time.Second {
+		t.Errorf("roundTrip = %s, expected 2s", rtt)
+	}
+}
+
+func TestDurationFromShort(t *testing.T) {
+	sh, err := NewShortFromDuration(90 * time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := durationFromShort(sh); got != 90*time.Second {
+		t.Errorf("durationFromShort(%v) = %s, expected 90s", sh, got)
+	}
+}
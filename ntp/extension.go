@@ -0,0 +1,164 @@
+package ntp
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+NTPv4 extension fields, as defined in RFC 5905 section 7.5:
+
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |          Field Type           |            Length             |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   .                                                               .
+   .                            Value                              .
+   .                                                               .
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                       Padding (as needed)                     |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+Field Type and Length are each 16 bits wide on the wire, giving a 4-byte
+header (not the 2-byte header earlier revisions of this package assumed,
+which silently truncated both the type and any Value over 255 bytes).
+*/
+
+// minExtensionFieldSize is the minimum total size (header + value +
+// padding) of an extension field, per RFC 5905 section 7.5.
+const minExtensionFieldSize = 16
+
+// maxExtensionFieldValue is the largest Value a single extension field can
+// carry: Length is a 16-bit wire field.
+const maxExtensionFieldValue = 0xffff
+
+// ExtensionField is a single NTPv4 extension field. Msg.Pack re-serializes
+// each field via Encode rather than touching raw bytes, so typed fields
+// (AutokeyField, NTSCookie, ...) round-trip through their structured form.
+type ExtensionField interface {
+	// Type is the field's 16-bit Field Type.
+	Type() uint16
+
+	// Encode returns the field's Value bytes, excluding the Type/Length
+	// header and any padding.
+	Encode() ([]byte, error)
+}
+
+// RawExtensionField is the fallback representation used for field types
+// with no registered decoder: its Value is kept verbatim.
+type RawExtensionField struct {
+	FieldType uint16
+	Value     []byte
+}
+
+func (e *RawExtensionField) Type() uint16            { return e.FieldType }
+func (e *RawExtensionField) Encode() ([]byte, error) { return e.Value, nil }
+
+// ExtensionDecoder builds a typed ExtensionField from a field's raw Value
+// bytes, for use with RegisterExtensionType.
+type ExtensionDecoder func(value []byte) (ExtensionField, error)
+
+var (
+	extensionRegistryMu sync.Mutex
+	extensionRegistry   = map[uint16]ExtensionDecoder{}
+)
+
+// RegisterExtensionType registers decoder as the way to parse extension
+// fields of the given type; Msg.Unpack then returns a typed ExtensionField
+// for it instead of a RawExtensionField. It's safe to call concurrently.
+func RegisterExtensionType(fieldType uint16, decoder ExtensionDecoder) {
+	extensionRegistryMu.Lock()
+	defer extensionRegistryMu.Unlock()
+	extensionRegistry[fieldType] = decoder
+}
+
+func decodeExtensionField(t uint16, value []byte) (ExtensionField, error) {
+	extensionRegistryMu.Lock()
+	decoder, ok := extensionRegistry[t]
+	extensionRegistryMu.Unlock()
+	if !ok {
+		return &RawExtensionField{FieldType: t, Value: append([]byte(nil), value...)}, nil
+	}
+	return decoder(append([]byte(nil), value...))
+}
+
+// alignedExtensionFieldSize returns how many bytes, including the 4-byte
+// Type/Length header, a field with an l-byte Value occupies once padded to
+// a 4-byte boundary and up to the RFC 5905 minimum field size.
+func alignedExtensionFieldSize(l int) int {
+	total := 4 + l
+	if r := total % 4; r != 0 {
+		total += 4 - r
+	}
+	if total < minExtensionFieldSize {
+		total = minExtensionFieldSize
+	}
+	return total
+}
+
+// unpackExtensionFields parses b as a sequence of extension fields, as
+// Msg.Unpack does with whatever lies between the header and the optional
+// KeyID/Dgst trailer.
+func (m *Msg) unpackExtensionFields(b []byte) error {
+	i := 0
+	for i < len(b) {
+		if i+4 > len(b) {
+			return fmt.Errorf("ntp: truncated extension field header at offset %d", i)
+		}
+		t := uint16(b[i])<<8 | uint16(b[i+1])
+		l := int(b[i+2])<<8 | int(b[i+3])
+
+		startVal := i + 4
+		endVal := startVal + l
+		if endVal > len(b) {
+			return fmt.Errorf("ntp: extension field value overruns buffer at offset %d", i)
+		}
+
+		aligned := alignedExtensionFieldSize(l)
+		if i+aligned > len(b) {
+			return fmt.Errorf("ntp: extension field padding overruns buffer at offset %d", i)
+		}
+
+		field, err := decodeExtensionField(t, b[startVal:endVal])
+		if err != nil {
+			return err
+		}
+		m.ExtensionFields = append(m.ExtensionFields, field)
+
+		i += aligned
+	}
+	return nil
+}
+
+// packExtensionFields serializes m.ExtensionFields into buf, returning the
+// number of bytes written.
+func (m *Msg) packExtensionFields(buf []byte) (n int, err error) {
+	i := 0
+	for _, ef := range m.ExtensionFields {
+		value, err := ef.Encode()
+		if err != nil {
+			return 0, err
+		}
+		if len(value) > maxExtensionFieldValue {
+			return 0, fmt.Errorf("ntp: extension field value too large: %d bytes", len(value))
+		}
+
+		aligned := alignedExtensionFieldSize(len(value))
+		if i+aligned > len(buf) {
+			return 0, fmt.Errorf("ntp: buffer too small for extension fields")
+		}
+
+		buf[i] = byte(ef.Type() >> 8)
+		buf[i+1] = byte(ef.Type())
+		buf[i+2] = byte(len(value) >> 8)
+		buf[i+3] = byte(len(value))
+		copy(buf[i+4:i+4+len(value)], value)
+		for j := i + 4 + len(value); j < i+aligned; j++ {
+			buf[j] = 0
+		}
+
+		i += aligned
+	}
+	return i, nil
+}
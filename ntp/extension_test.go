@@ -0,0 +1,289 @@
+package ntp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnpackExtensionFieldsMalformed(t *testing.T) {
+	cases := []struct {
+		name string
+		b    []byte
+	}{
+		{"truncated header", []byte{0x00, 0x01, 0x00}},
+		{"value overruns buffer", []byte{0x00, 0x01, 0x00, 0x08, 0, 0}},
+		{"padding overruns buffer", []byte{0x00, 0x01, 0x00, 0x02, 0, 0}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := &Msg{}
+			if err := m.unpackExtensionFields(c.b); err == nil {
+				t.Errorf("unpackExtensionFields(%x) = nil error, expected one", c.b)
+			}
+		})
+	}
+}
+
+func TestRawExtensionFieldRoundTrip(t *testing.T) {
+	const unregisteredType uint16 = 0xfe
+	raw := &RawExtensionField{FieldType: unregisteredType, Value: []byte("hello")}
+
+	m := &Msg{ExtensionFields: []ExtensionField{raw}}
+	buf := make([]byte, 64)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	if len(got.ExtensionFields) != 1 {
+		t.Fatalf("got %d extension fields, expected 1", len(got.ExtensionFields))
+	}
+	field, ok := got.ExtensionFields[0].(*RawExtensionField)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *RawExtensionField", got.ExtensionFields[0])
+	}
+	if field.FieldType != unregisteredType || !bytes.Equal(field.Value, raw.Value) {
+		t.Errorf("got %+v, expected %+v", field, raw)
+	}
+}
+
+func TestAutokeyFieldRoundTrip(t *testing.T) {
+	want := NewAutokeyField(0xdeadbeef, []byte("a signature longer than the minimum field size"))
+
+	m := &Msg{ExtensionFields: []ExtensionField{want}}
+	buf := make([]byte, 128)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	field, ok := got.ExtensionFields[0].(*AutokeyField)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *AutokeyField", got.ExtensionFields[0])
+	}
+	if field.KeyID != want.KeyID || !bytes.Equal(field.Signature, want.Signature) {
+		t.Errorf("got %+v, expected %+v", field, want)
+	}
+}
+
+func TestNTSUniqueIdentifierRoundTrip(t *testing.T) {
+	nonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	want := NewNTSUniqueIdentifier(nonce)
+
+	m := &Msg{ExtensionFields: []ExtensionField{want}}
+	buf := make([]byte, 64)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	field, ok := got.ExtensionFields[0].(*NTSUniqueIdentifier)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSUniqueIdentifier", got.ExtensionFields[0])
+	}
+	if !bytes.Equal(field.Nonce(), nonce) {
+		t.Errorf("Nonce() = %x, expected %x", field.Nonce(), nonce)
+	}
+}
+
+func TestNTSCookieRoundTrip(t *testing.T) {
+	cookie := bytes.Repeat([]byte{0xab}, 20)
+	want := NewNTSCookie(cookie)
+
+	m := &Msg{ExtensionFields: []ExtensionField{want}}
+	buf := make([]byte, 64)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	field, ok := got.ExtensionFields[0].(*NTSCookie)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSCookie", got.ExtensionFields[0])
+	}
+	if !bytes.Equal(field.Cookie(), cookie) {
+		t.Errorf("Cookie() = %x, expected %x", field.Cookie(), cookie)
+	}
+}
+
+func TestNTSCookiePlaceholderRoundTrip(t *testing.T) {
+	want := NewNTSCookiePlaceholder(20)
+
+	m := &Msg{ExtensionFields: []ExtensionField{want}}
+	buf := make([]byte, 64)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	field, ok := got.ExtensionFields[0].(*NTSCookiePlaceholder)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSCookiePlaceholder", got.ExtensionFields[0])
+	}
+	if len(field.body) != 20 {
+		t.Errorf("placeholder length = %d, expected 20", len(field.body))
+	}
+}
+
+func TestNTSAuthenticatorRoundTrip(t *testing.T) {
+	nonce := []byte{1, 2, 3, 4, 5}
+	ciphertext := []byte("some encrypted extension fields and a tag")
+	want := NewNTSAuthenticator(nonce, ciphertext)
+
+	m := &Msg{ExtensionFields: []ExtensionField{want}}
+	buf := make([]byte, 128)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	field, ok := got.ExtensionFields[0].(*NTSAuthenticator)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSAuthenticator", got.ExtensionFields[0])
+	}
+	if !bytes.Equal(field.Nonce(), nonce) {
+		t.Errorf("Nonce() = %x, expected %x", field.Nonce(), nonce)
+	}
+	if !bytes.Equal(field.Ciphertext(), ciphertext) {
+		t.Errorf("Ciphertext() = %x, expected %x", field.Ciphertext(), ciphertext)
+	}
+}
+
+func TestNTSAuthenticatorRoundTripLargeCiphertext(t *testing.T) {
+	// A realistic NTS ciphertext routinely exceeds 255 bytes; the wire
+	// Length field is 16 bits, not 8, so this must round-trip intact rather
+	// than getting truncated mod 256.
+	nonce := []byte{1, 2, 3, 4}
+	ciphertext := bytes.Repeat([]byte{0xcd}, 300)
+	want := NewNTSAuthenticator(nonce, ciphertext)
+
+	m := &Msg{ExtensionFields: []ExtensionField{want}}
+	buf := make([]byte, 512)
+	n, err := m.packExtensionFields(buf)
+	if err != nil {
+		t.Fatalf("packExtensionFields: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(buf[:n]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	field, ok := got.ExtensionFields[0].(*NTSAuthenticator)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSAuthenticator", got.ExtensionFields[0])
+	}
+	if !bytes.Equal(field.Ciphertext(), ciphertext) {
+		t.Errorf("Ciphertext() length = %d, expected %d", len(field.Ciphertext()), len(ciphertext))
+	}
+}
+
+func TestPackExtensionFieldsValueTooLarge(t *testing.T) {
+	field := NewNTSCookie(make([]byte, maxExtensionFieldValue+1))
+	m := &Msg{ExtensionFields: []ExtensionField{field}}
+	buf := make([]byte, maxExtensionFieldValue+64)
+	if _, err := m.packExtensionFields(buf); err == nil {
+		t.Error("packExtensionFields with an oversized value = nil error, expected one")
+	}
+}
+
+func TestMsgPackUsesEncodeNotRawBytes(t *testing.T) {
+	// Msg.Pack's extension-field handling is just a thin wrapper around
+	// packExtensionFields (see its Version check in ntp.go), so driving it
+	// through Msg.Pack directly, rather than re-testing packExtensionFields
+	// again, confirms the two are wired together correctly.
+	field := NewNTSCookie([]byte{1, 2, 3})
+
+	m := &Msg{Header: MsgHeader{Version: 4}, ExtensionFields: []ExtensionField{field}}
+	buf := make([]byte, HeaderSize+64)
+	packed, err := m.Pack(buf, nil)
+	if err != nil {
+		t.Fatalf("Pack: %s", err)
+	}
+
+	got := &Msg{}
+	if err := got.unpackExtensionFields(packed[HeaderSize:]); err != nil {
+		t.Fatalf("unpackExtensionFields: %s", err)
+	}
+	gotField, ok := got.ExtensionFields[0].(*NTSCookie)
+	if !ok {
+		t.Fatalf("got field of type %T, expected *NTSCookie", got.ExtensionFields[0])
+	}
+	if !bytes.Equal(gotField.Cookie(), []byte{1, 2, 3}) {
+		t.Errorf("Cookie() = %x, expected 010203", gotField.Cookie())
+	}
+}
+
+func TestMsgPackUnpackExtensionFields(t *testing.T) {
+	field := NewNTSCookie([]byte{1, 2, 3})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		m := &Msg{Header: MsgHeader{Version: 4, Mode: ClientMode}, ExtensionFields: []ExtensionField{field}}
+		buf := make([]byte, HeaderSize+64)
+		packed, err := m.Pack(buf, nil)
+		if err != nil {
+			t.Fatalf("Pack: %s", err)
+		}
+
+		var got Msg
+		if err := got.Unpack(packed, nil); err != nil {
+			t.Fatalf("Unpack: %s", err)
+		}
+		gotField, ok := got.ExtensionFields[0].(*NTSCookie)
+		if !ok {
+			t.Fatalf("got field of type %T, expected *NTSCookie", got.ExtensionFields[0])
+		}
+		if !bytes.Equal(gotField.Cookie(), []byte{1, 2, 3}) {
+			t.Errorf("Cookie() = %x, expected 010203", gotField.Cookie())
+		}
+	})
+
+	t.Run("authenticated", func(t *testing.T) {
+		ks := mapKeyStore{1: {key: []byte("shared-secret"), algo: HashMD5}}
+
+		m := &Msg{Header: MsgHeader{Version: 4, Mode: ClientMode}, ExtensionFields: []ExtensionField{field}}
+		buf := make([]byte, HeaderSize+64+4+HashMD5.Size())
+		packed, err := m.Pack(buf, m.Sign(1, []byte("shared-secret")))
+		if err != nil {
+			t.Fatalf("Pack: %s", err)
+		}
+
+		var got Msg
+		if err := got.Unpack(packed, ks); err != nil {
+			t.Fatalf("Unpack: %s", err)
+		}
+		if got.KeyID != 1 {
+			t.Errorf("KeyID = %d, expected 1", got.KeyID)
+		}
+		gotField, ok := got.ExtensionFields[0].(*NTSCookie)
+		if !ok {
+			t.Fatalf("got field of type %T, expected *NTSCookie", got.ExtensionFields[0])
+		}
+		if !bytes.Equal(gotField.Cookie(), []byte{1, 2, 3}) {
+			t.Errorf("Cookie() = %x, expected 010203", gotField.Cookie())
+		}
+	})
+}
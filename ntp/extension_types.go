@@ -0,0 +1,188 @@
+package ntp
+
+import "fmt"
+
+// Built-in extension field types. These are placeholder values, not the
+// real IANA-assigned field types for RFC 5906 Autokey or RFC 8915 NTS
+// fields; swap them for the real assignments before talking to another
+// implementation.
+const (
+	ExtAutokey              uint16 = 0x02
+	ExtNTSUniqueIdentifier  uint16 = 0x04
+	ExtNTSCookie            uint16 = 0x05
+	ExtNTSCookiePlaceholder uint16 = 0x06
+	ExtNTSAuthenticator     uint16 = 0x07
+)
+
+func init() {
+	RegisterExtensionType(ExtAutokey, decodeAutokeyField)
+	RegisterExtensionType(ExtNTSUniqueIdentifier, decodeNTSUniqueIdentifier)
+	RegisterExtensionType(ExtNTSCookie, decodeNTSCookie)
+	RegisterExtensionType(ExtNTSCookiePlaceholder, decodeNTSCookiePlaceholder)
+	RegisterExtensionType(ExtNTSAuthenticator, decodeNTSAuthenticator)
+}
+
+// AutokeyField is an RFC 5906 Autokey extension field: a key identifier
+// followed by a signature over the rest of the message.
+type AutokeyField struct {
+	KeyID     uint32
+	Signature []byte
+}
+
+// NewAutokeyField builds an AutokeyField from its key identifier and
+// signature.
+func NewAutokeyField(keyID uint32, signature []byte) *AutokeyField {
+	return &AutokeyField{KeyID: keyID, Signature: append([]byte(nil), signature...)}
+}
+
+func (a *AutokeyField) Type() uint16 { return ExtAutokey }
+
+func (a *AutokeyField) Encode() ([]byte, error) {
+	buf := make([]byte, 4+len(a.Signature))
+	buf[0] = byte(a.KeyID >> 24)
+	buf[1] = byte(a.KeyID >> 16)
+	buf[2] = byte(a.KeyID >> 8)
+	buf[3] = byte(a.KeyID)
+	copy(buf[4:], a.Signature)
+	return buf, nil
+}
+
+func decodeAutokeyField(value []byte) (ExtensionField, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("ntp: autokey field too short: %d bytes", len(value))
+	}
+	keyID := uint32(value[0])<<24 | uint32(value[1])<<16 | uint32(value[2])<<8 | uint32(value[3])
+	return NewAutokeyField(keyID, value[4:]), nil
+}
+
+// NTSUniqueIdentifier is the RFC 8915 section 5.7 Unique Identifier
+// extension field: a client-chosen nonce echoed back by the server so the
+// client can match a response to its request.
+type NTSUniqueIdentifier struct {
+	nonce []byte
+}
+
+// NewNTSUniqueIdentifier builds an NTSUniqueIdentifier from a nonce.
+func NewNTSUniqueIdentifier(nonce []byte) *NTSUniqueIdentifier {
+	return &NTSUniqueIdentifier{nonce: append([]byte(nil), nonce...)}
+}
+
+// Nonce returns the field's nonce.
+func (u *NTSUniqueIdentifier) Nonce() []byte { return append([]byte(nil), u.nonce...) }
+
+func (u *NTSUniqueIdentifier) Type() uint16            { return ExtNTSUniqueIdentifier }
+func (u *NTSUniqueIdentifier) Encode() ([]byte, error) { return u.nonce, nil }
+
+func decodeNTSUniqueIdentifier(value []byte) (ExtensionField, error) {
+	return NewNTSUniqueIdentifier(value), nil
+}
+
+// NTSCookie is the RFC 8915 section 5.7 NTS Cookie extension field: an
+// opaque, server-issued cookie the client presents on its next request.
+type NTSCookie struct {
+	cookie []byte
+}
+
+// NewNTSCookie builds an NTSCookie from its opaque cookie bytes.
+func NewNTSCookie(cookie []byte) *NTSCookie {
+	return &NTSCookie{cookie: append([]byte(nil), cookie...)}
+}
+
+// Cookie returns the field's opaque cookie bytes.
+func (c *NTSCookie) Cookie() []byte { return append([]byte(nil), c.cookie...) }
+
+func (c *NTSCookie) Type() uint16            { return ExtNTSCookie }
+func (c *NTSCookie) Encode() ([]byte, error) { return c.cookie, nil }
+
+func decodeNTSCookie(value []byte) (ExtensionField, error) {
+	return NewNTSCookie(value), nil
+}
+
+// NTSCookiePlaceholder is the RFC 8915 section 5.7 NTS Cookie Placeholder
+// extension field: padding, the same length as a real NTS Cookie, that a
+// client sends to ask the server for extra spare cookies.
+type NTSCookiePlaceholder struct {
+	body []byte
+}
+
+// NewNTSCookiePlaceholder builds a placeholder of the given length, matching
+// the length of the NTSCookie it's meant to request a replacement for.
+func NewNTSCookiePlaceholder(length int) *NTSCookiePlaceholder {
+	return &NTSCookiePlaceholder{body: make([]byte, length)}
+}
+
+func (p *NTSCookiePlaceholder) Type() uint16            { return ExtNTSCookiePlaceholder }
+func (p *NTSCookiePlaceholder) Encode() ([]byte, error) { return p.body, nil }
+
+func decodeNTSCookiePlaceholder(value []byte) (ExtensionField, error) {
+	return &NTSCookiePlaceholder{body: append([]byte(nil), value...)}, nil
+}
+
+// NTSAuthenticator is the RFC 8915 section 5.6 NTS Authenticator and
+// Encrypted Extension Fields field: an AEAD nonce and ciphertext protecting
+// the rest of the message.
+type NTSAuthenticator struct {
+	nonce      []byte
+	ciphertext []byte
+}
+
+// NewNTSAuthenticator builds an NTSAuthenticator from its nonce and
+// ciphertext.
+func NewNTSAuthenticator(nonce, ciphertext []byte) *NTSAuthenticator {
+	return &NTSAuthenticator{
+		nonce:      append([]byte(nil), nonce...),
+		ciphertext: append([]byte(nil), ciphertext...),
+	}
+}
+
+// Nonce returns the field's AEAD nonce.
+func (a *NTSAuthenticator) Nonce() []byte { return append([]byte(nil), a.nonce...) }
+
+// Ciphertext returns the field's AEAD ciphertext.
+func (a *NTSAuthenticator) Ciphertext() []byte { return append([]byte(nil), a.ciphertext...) }
+
+func (a *NTSAuthenticator) Type() uint16 { return ExtNTSAuthenticator }
+
+// Encode lays out the nonce and ciphertext length-prefixed and individually
+// padded to a 4-byte boundary, per RFC 8915 section 5.6.
+func (a *NTSAuthenticator) Encode() ([]byte, error) {
+	noncePad := pad4(len(a.nonce))
+	ctPad := pad4(len(a.ciphertext))
+
+	buf := make([]byte, 4+len(a.nonce)+noncePad+len(a.ciphertext)+ctPad)
+	buf[0] = byte(len(a.nonce) >> 8)
+	buf[1] = byte(len(a.nonce))
+	buf[2] = byte(len(a.ciphertext) >> 8)
+	buf[3] = byte(len(a.ciphertext))
+
+	copy(buf[4:], a.nonce)
+	copy(buf[4+len(a.nonce)+noncePad:], a.ciphertext)
+	return buf, nil
+}
+
+func decodeNTSAuthenticator(value []byte) (ExtensionField, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("ntp: NTS authenticator field too short: %d bytes", len(value))
+	}
+	nonceLen := int(value[0])<<8 | int(value[1])
+	ctLen := int(value[2])<<8 | int(value[3])
+
+	nonceStart := 4
+	nonceEnd := nonceStart + nonceLen
+	ctStart := nonceEnd + pad4(nonceLen)
+	ctEnd := ctStart + ctLen
+	if ctEnd > len(value) {
+		return nil, fmt.Errorf("ntp: NTS authenticator field too short for its declared lengths")
+	}
+
+	return NewNTSAuthenticator(value[nonceStart:nonceEnd], value[ctStart:ctEnd]), nil
+}
+
+// pad4 returns how many padding bytes bring an n-byte value up to a 4-byte
+// boundary.
+func pad4(n int) int {
+	if r := n % 4; r != 0 {
+		return 4 - r
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package ntp
+
+import (
+	"fmt"
+	"time"
+)
+
+// MarshalText renders ts as an RFC 3339 timestamp with nanosecond precision,
+// e.g. "2014-10-13T14:00:00.123456789Z". This goes through TimeFromTimestampInEra
+// rather than a single int64 nanosecond count, so eras beyond what an int64
+// nanosecond count can hold still round-trip correctly.
+func (ts Timestamp) MarshalText() ([]byte, error) {
+	t := TimeFromTimestampInEra(ts, ts.Era)
+	return []byte(t.Format(time.RFC3339Nano)), nil
+}
+
+// UnmarshalText parses an RFC 3339 timestamp produced by MarshalText (or any
+// other conforming RFC 3339 string) back into ts, selecting whichever era
+// contains the parsed time.
+func (ts *Timestamp) UnmarshalText(data []byte) error {
+	t, err := time.Parse(time.RFC3339, string(data))
+	if err != nil {
+		return err
+	}
+	parsed, err := NewTimestampFromTime(t)
+	if err != nil {
+		return err
+	}
+	*ts = parsed
+	return nil
+}
+
+// MarshalJSON renders ts as a JSON string, using the same RFC 3339
+// representation as MarshalText.
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	text, err := ts.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return quoteJSON(text), nil
+}
+
+// UnmarshalJSON parses a JSON string produced by MarshalJSON (or any other
+// conforming RFC 3339 string) back into ts. A JSON null leaves ts unchanged.
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text, err := unquoteJSON(data)
+	if err != nil {
+		return fmt.Errorf("ntp: Timestamp must be a JSON string: %w", err)
+	}
+	return ts.UnmarshalText(text)
+}
+
+// MarshalText renders s as a Go time.Duration string, e.g. "1h30m0s".
+func (s Short) MarshalText() ([]byte, error) {
+	return []byte(durationFromShort(s).String()), nil
+}
+
+// UnmarshalText parses a Go time.Duration string produced by MarshalText
+// back into s.
+func (s *Short) UnmarshalText(data []byte) error {
+	d, err := time.ParseDuration(string(data))
+	if err != nil {
+		return err
+	}
+	parsed, err := NewShortFromDuration(d)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// MarshalJSON renders s as a JSON string, using the same time.Duration
+// representation as MarshalText.
+func (s Short) MarshalJSON() ([]byte, error) {
+	text, err := s.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return quoteJSON(text), nil
+}
+
+// UnmarshalJSON parses a JSON string produced by MarshalJSON back into s. A
+// JSON null leaves s unchanged.
+func (s *Short) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text, err := unquoteJSON(data)
+	if err != nil {
+		return fmt.Errorf("ntp: Short must be a JSON string: %w", err)
+	}
+	return s.UnmarshalText(text)
+}
+
+func quoteJSON(text []byte) []byte {
+	buf := make([]byte, 0, len(text)+2)
+	buf = append(buf, '"')
+	buf = append(buf, text...)
+	buf = append(buf, '"')
+	return buf
+}
+
+func unquoteJSON(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return nil, fmt.Errorf("not a JSON string: %s", data)
+	}
+	return data[1 : len(data)-1], nil
+}
@@ -0,0 +1,79 @@
+package ntp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestampTextJSON(t *testing.T) {
+	when := time.Date(2014, 10, 13, 14, 0, 0, 123456789, time.UTC)
+	ts := mustTimestamp(t, when)
+
+	text, err := ts.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText: %s", err)
+	}
+	// The NTP fraction only has ~30 bits of precision (see the comment on
+	// NewTimestampFromTimeInEra's random low-order bits), so the last
+	// nanosecond digit or two may not round-trip exactly.
+	if want := "2014-10-13T14:00:00.12345678"; !strings.HasPrefix(string(text), want) {
+		t.Errorf("MarshalText = %q, expected prefix %q", text, want)
+	}
+
+	var got Timestamp
+	if err := got.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText: %s", err)
+	}
+	if tt := TimeFromTimestampInEra(got, got.Era); !matchTimeApprox(tt, when) {
+		t.Errorf("round-trip = %s, expected %s", tt, when)
+	}
+
+	b, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatalf("json.Marshal: %s", err)
+	}
+	var got2 Timestamp
+	if err := json.Unmarshal(b, &got2); err != nil {
+		t.Fatalf("json.Unmarshal: %s", err)
+	}
+	if tt := TimeFromTimestampInEra(got2, got2.Era); !matchTimeApprox(tt, when) {
+		t.Errorf("json round-trip = %s, expected %s", tt, when)
+	}
+}
+
+func TestShortTextJSON(t *testing.T) {
+	sh, err := NewShortFromDuration(90 * time.Minute)
+	if err != nil {
+		t.Fatalf("NewShortFromDuration: %s", err)
+	}
+
+	text, err := sh.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText: %s", err)
+	}
+	if want := "1h30m0s"; string(text) != want {
+		t.Errorf("MarshalText = %q, expected %q", text, want)
+	}
+
+	var got Short
+	if err := got.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText: %s", err)
+	}
+	if !matchShortExact(got, sh) {
+		t.Errorf("round-trip = %v, expected %v", got, sh)
+	}
+
+	b, err := json.Marshal(sh)
+	if err != nil {
+		t.Fatalf("json.Marshal: %s", err)
+	}
+	var got2 Short
+	if err := json.Unmarshal(b, &got2); err != nil {
+		t.Fatalf("json.Unmarshal: %s", err)
+	}
+	if !matchShortExact(got2, sh) {
+		t.Errorf("json round-trip = %v, expected %v", got2, sh)
+	}
+}
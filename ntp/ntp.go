@@ -1,8 +1,12 @@
 package ntp
 
 import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"hash"
 	"math"
 	"math/rand"
 	"time"
@@ -23,6 +27,68 @@ const HeaderSize = 48
 
 var ErrOverflow = errors.New("Overflow")
 
+// ErrAuthFailed is returned by Msg.Unpack when a message's digest doesn't
+// match the one recomputed from the key its KeyID names, or when that KeyID
+// is unknown to the KeyStore passed in.
+var ErrAuthFailed = errors.New("ntp: authentication failed")
+
+// HashAlgo identifies the hash used to compute a Msg's authentication digest,
+// per RFC 5905 section 7.3 (MD5) and its common SHA-1 extension.
+type HashAlgo uint8
+
+const (
+	HashMD5 HashAlgo = iota
+	HashSHA1
+)
+
+// Size returns the digest length, in bytes, produced by a.
+func (a HashAlgo) Size() int {
+	switch a {
+	case HashSHA1:
+		return sha1.Size
+	default:
+		return md5.Size
+	}
+}
+
+func (a HashAlgo) new() hash.Hash {
+	switch a {
+	case HashSHA1:
+		return sha1.New()
+	default:
+		return md5.New()
+	}
+}
+
+// KeyStore looks up the symmetric key and hash algorithm associated with a
+// Msg's KeyID, for use by Pack and Unpack.
+type KeyStore interface {
+	Lookup(keyID uint32) (key []byte, algo HashAlgo, ok bool)
+}
+
+// singleKeyStore is the KeyStore returned by Msg.Sign.
+type singleKeyStore struct {
+	keyID uint32
+	key   []byte
+	algo  HashAlgo
+}
+
+func (s singleKeyStore) Lookup(keyID uint32) ([]byte, HashAlgo, bool) {
+	if keyID != s.keyID {
+		return nil, 0, false
+	}
+	return s.key, s.algo, true
+}
+
+// digest computes the NTP symmetric-key authentication digest: the hash of
+// key followed by data (the packet header and extension fields).
+func digest(algo HashAlgo, key, data []byte) []byte {
+	h := algo.new()
+	h.Write(key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
 var NTPEpoch = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
 
 type exponent int8
@@ -50,6 +116,27 @@ type Short struct {
 type Timestamp struct {
 	Seconds  uint32
 	Fraction uint32
+
+	// Era identifies which 2^32-second NTP era Seconds counts from, as
+	// defined in RFC 5905 section 7.2. Era 0 is the default zero value and
+	// covers 1900-01-01 through 2036-02-07, matching historical behavior.
+	Era int32
+}
+
+// eraSeconds is the width of a single NTP era: 2^32 seconds.
+const eraSeconds = int64(1) << 32
+
+// floorDivMod returns the quotient and remainder of total/div, rounding the
+// quotient towards negative infinity (unlike Go's truncating /, %), so that
+// the remainder is always in [0, div).
+func floorDivMod(total, div int64) (q, r int64) {
+	q = total / div
+	r = total % div
+	if r != 0 && (r < 0) != (div < 0) {
+		q--
+		r += div
+	}
+	return q, r
 }
 
 /*
@@ -64,33 +151,75 @@ type Timestamp struct {
       timestamp.
 */
 
-// NewTimestampFromTime converts a time object to its NTP Timestamp representation..
-// TODO: reread spec about era. Most likely I need a new function to take that into account
-// this one can focus on era 0 or something.
+// NewTimestampFromTime converts a time object to its NTP Timestamp representation,
+// picking whichever era contains t.
 func NewTimestampFromTime(t time.Time) (Timestamp, error) {
-	ts := Timestamp{}
+	era, _ := floorDivMod(t.Unix()-NTPEpoch.Unix(), eraSeconds)
+	return NewTimestampFromTimeInEra(t, int32(era))
+}
 
-	ns := t.Sub(NTPEpoch).Nanoseconds()
-	s := ns / 1e9
-	if s > int64(0xffffffff) || s < -int64(0xffffffff) {
-		return ts, fmt.Errorf("Timestamp overflow: %s", t) // TODO(yann): overflow value error?
+// NewTimestampFromTimeInEra converts a time object to its NTP Timestamp
+// representation within a specific era, as defined in RFC 5905 section 7.2.
+// It fails if t does not fall within era.
+//
+// Splitting the conversion into a whole-seconds part (via t.Unix(), which
+// stays within int64 range for any time.Time) and a sub-second part (via
+// t.Nanosecond()) avoids routing dates far from the Unix epoch through a
+// single int64 nanosecond count, which would overflow long before the NTP
+// eras themselves do.
+func NewTimestampFromTimeInEra(t time.Time, era int32) (Timestamp, error) {
+	ts := Timestamp{Era: era}
+
+	total := t.Unix() - NTPEpoch.Unix()
+	s := total - int64(era)*eraSeconds
+	if s < 0 || s > int64(0xffffffff) {
+		return Timestamp{}, fmt.Errorf("Timestamp overflow: %s is not within era %d", t, era) // TODO(yann): overflow value error?
 	}
-	f := ns % 1e9
 	ts.Seconds = uint32(s)
 
 	// the time package exposes a nanosecond precision, which can be represented with a
 	// 30bit fraction. So, in accordance with the RFC the last 2 LSB are randomly selected
 	source := rand.NewSource(time.Now().UnixNano())
 	lsb := rand.New(source).Intn(4)
-	ts.Fraction = uint32(f << 32 / 1e9)
+	ts.Fraction = uint32(int64(t.Nanosecond()) << 32 / 1e9)
 	ts.Fraction = ts.Fraction>>2<<2 | uint32(lsb)
 	return ts, nil
 }
 
-// TimeFromTimestamp returns the closest time.Time object that can represent ts.
+// TimeFromTimestamp returns the closest time.Time object that can represent ts,
+// auto-selecting the era containing "now ± 68 years" as documented in RFC 5905
+// section 7.2. The wire format carries no era, so ts.Era is ignored here; call
+// TimeFromTimestampInEra directly when the era is already known.
 // TODO: Take precision into account
 func TimeFromTimestamp(ts Timestamp) time.Time {
-	return NTPEpoch.Add(time.Duration(int64(ts.Seconds)*1e9 + int64(ts.Fraction)*1e9>>32))
+	return TimeFromTimestampInEra(ts, int32(eraNearNow(ts.Seconds)))
+}
+
+// TimeFromTimestampInEra returns the time.Time object represented by ts within
+// a specific era.
+//
+// The whole-seconds and sub-second parts are combined via time.Unix rather
+// than by building a single time.Duration, since eras far from era 0 push the
+// equivalent nanosecond count well past what an int64 Duration can hold.
+func TimeFromTimestampInEra(ts Timestamp, era int32) time.Time {
+	total := int64(era)*eraSeconds + int64(ts.Seconds)
+	ns := int64(ts.Fraction) * 1e9 >> 32
+	return time.Unix(NTPEpoch.Unix()+total, ns).UTC()
+}
+
+// eraNearNow returns the era whose Seconds field, combined with secs, yields
+// a time within half an era (~68 years) of now, resolving the ambiguity
+// inherent in a 32-bit seconds field the way RFC 5905 section 7.2 describes.
+func eraNearNow(secs uint32) int64 {
+	now := time.Now().Unix() - NTPEpoch.Unix()
+	nowEra, nowSecs := floorDivMod(now, eraSeconds)
+	diff := int64(secs) - nowSecs
+	if diff > eraSeconds/2 {
+		nowEra--
+	} else if diff < -eraSeconds/2 {
+		nowEra++
+	}
+	return nowEra
 }
 
 // NewShortFromDuration return a Short.
@@ -112,7 +241,7 @@ type Msg struct {
 	Header          MsgHeader
 	ExtensionFields []ExtensionField
 	KeyID           uint32
-	Dgst            [16]byte
+	Dgst            []byte
 }
 
 // MsgHeader is the mandatory header variables. Its values are common between
@@ -137,10 +266,6 @@ type MsgHeader struct {
 	Dst     Timestamp // T4
 }
 
-type ClientMsg struct {
-	*Msg
-}
-
 // TODO(yann) struct methods?
 func packShort(st Short, b []byte) {
 	b[0] = byte(st.Seconds >> 8)
@@ -204,128 +329,127 @@ func (mh *MsgHeader) Pack(buf []byte) (msg []byte, err error) {
 }
 
 // Pack writes a NTP Msg to a buf byte slice.
-// if buf is too small a new slice is allocated. (TODO, is it what we want?)
-func (m *Msg) Pack(buf []byte) (msg []byte, err error) {
+//
+// If m.KeyID is non-zero and ks is non-nil, the message is authenticated per
+// RFC 5905 section 7.3: ks.Lookup(m.KeyID) supplies the symmetric key and
+// hash algorithm, and the resulting digest (computed over the key followed
+// by the header and extension fields) is appended after the key ID. Pass a
+// nil ks (or leave m.KeyID zero) to send an unauthenticated message; see also
+// Sign for a convenient way to set up ks for a single key.
+//
+// buf must be large enough for the header, extension fields, and (if
+// authenticated) the KeyID/digest trailer; Pack returns an error rather than
+// grow it.
+func (m *Msg) Pack(buf []byte, ks KeyStore) (msg []byte, err error) {
+	if len(buf) < HeaderSize {
+		return nil, fmt.Errorf("ntp: buffer too small for header")
+	}
 	m.Header.Pack(buf)
 	if m.Header.Version < 4 {
-		return buf, nil
+		return buf[:HeaderSize], nil
 	}
-	m.packExtensionFields(buf[len(buf):cap(buf)])
-	i := 0 // FIXME
 
-	buf[i] = byte(m.KeyID >> 24)
-	buf[i+1] = byte(m.KeyID >> 16 & 0xff0000)
-	buf[i+2] = byte(m.KeyID >> 8 & 0xff00)
-	buf[i+3] = byte(m.KeyID & 0xff)
-	i = i + 3
+	extLen, err := m.packExtensionFields(buf[HeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+	end := HeaderSize + extLen
 
-	for j := 0; j < 16; i++ {
-		buf[i+j] = m.Dgst[i]
+	if ks == nil || m.KeyID == 0 {
+		return buf[:end], nil
 	}
 
-	return buf, nil
+	key, algo, ok := ks.Lookup(m.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("ntp: no key found for key id %d", m.KeyID)
+	}
+
+	if end+4+algo.Size() > len(buf) {
+		return nil, fmt.Errorf("ntp: buffer too small for KeyID/digest trailer")
+	}
+
+	buf[end] = byte(m.KeyID >> 24)
+	buf[end+1] = byte(m.KeyID >> 16 & 0xff)
+	buf[end+2] = byte(m.KeyID >> 8 & 0xff)
+	buf[end+3] = byte(m.KeyID & 0xff)
+
+	m.Dgst = digest(algo, key, buf[:end])
+	copy(buf[end+4:end+4+len(m.Dgst)], m.Dgst)
+
+	return buf[:end+4+len(m.Dgst)], nil
 }
 
-func (m *Msg) Unpack(b []byte) error {
-	err := m.Header.Unpack(b)
-	if err != nil {
+// Sign sets m.KeyID and returns a KeyStore holding key under that ID, using
+// the MD5 algorithm, for use with Pack:
+//
+//	msg.Header.Xmt, _ = NewTimestampFromTime(time.Now())
+//	packed, err := msg.Pack(buf, msg.Sign(keyID, key))
+func (m *Msg) Sign(keyID uint32, key []byte) KeyStore {
+	m.KeyID = keyID
+	return singleKeyStore{keyID: keyID, key: key, algo: HashMD5}
+}
+
+// Unpack reads a NTP Msg from a byte slice into m.
+//
+// Pack appends the KeyID/Dgst trailer after any extension fields, not right
+// after the header, so if ks is non-nil Unpack locates that trailer from the
+// end of b instead: the digest length (and so where the 4-byte KeyID before
+// it starts) isn't known until the KeyID is looked up, so Unpack tries each
+// HashAlgo's digest length in turn, accepting the first one whose KeyID
+// resolves via ks.Lookup to that same algorithm. The digest is then
+// recomputed and compared against the one in b, returning ErrAuthFailed on
+// any mismatch.
+//
+// Configuring ks doesn't force every message to carry that trailer: NTS
+// (RFC 8915) secures a message entirely through its extension fields and
+// never uses it, so Unpack first tries parsing all of b after the header as
+// plain extension fields; it only falls back to looking for a KeyID/Dgst
+// trailer, and only then returns ErrAuthFailed on failure, when that parse
+// doesn't cleanly consume the whole buffer. Pass a nil ks to always skip the
+// trailer and treat every byte after the header as opaque extension fields.
+//
+// TODO(yann): should we return an error? What would be the error? Panic / catch Out of bounds?
+func (m *Msg) Unpack(b []byte, ks KeyStore) error {
+	if err := m.Header.Unpack(b); err != nil {
 		return err
 	}
-	if m.Header.Version < 4 {
+	if m.Header.Version < 4 || len(b) <= HeaderSize {
 		return nil
 	}
-	end := len(b) + 1
-	remain := end - HeaderSize
-	if remain > 1 {
-		if remain < 4+len(m.Dgst)+5 { // keyid + dgst + min(extfield)
-			return fmt.Errorf("not enough data following header: %d bytes", remain)
-		}
-		/*
-			Key Identifier (keyid): 32-bit unsigned integer used by the client
-			and server to designate a secret 128-bit MD5 key.
-
-			Message Digest (digest): 128-bit MD5 hash computed over the key
-			followed by the NTP packet header and extensions fields (but not the
-			Key Identifier or Message Digest fields).
-		*/
-		// We start by the end of the byte buffer to get the fixed
-		// size fields.
-		for i := 15; i >= 0; i-- {
-			m.Dgst[i] = b[end-i-1]
-		}
-
-		end = end - len(m.Dgst) // end of KeyID: before Dgst
-		m.KeyID = uint32(b[end-4])<<24 | uint32(b[end-3])<<16 | uint32(b[end-2])<<8 | uint32(b[end-1])
 
-		end = end - 4 // e is now the end of extension fields
-		m.unpackExtensionFields(b[HeaderSize:end])
+	rest := b[HeaderSize:]
+	if ks == nil {
+		return m.unpackExtensionFields(rest)
 	}
 
-	return nil
-}
-
-/*
-    0                   1                   2                   3
-    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
-   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
-   |          Field Type           |            Length             |
-   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
-   .                                                               .
-   .                            Value                              .
-   .                                                               .
-   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
-   |                       Padding (as needed)                     |
-   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
-*/
-
-// ExtensionFields is the NTPv4 extensions as defined in RFC5905 Section 7.5
-type ExtensionField struct {
-	Type  byte
-	Value []byte
-}
+	probe := &Msg{}
+	if err := probe.unpackExtensionFields(rest); err == nil {
+		m.ExtensionFields = probe.ExtensionFields
+		return nil
+	}
 
-// Len returns the length of the ExtensionField Value.
-func (e *ExtensionField) Len() uint8 {
-	return uint8(len(e.Value))
-}
+	for _, algo := range []HashAlgo{HashMD5, HashSHA1} {
+		trailer := 4 + algo.Size()
+		if len(rest) < trailer {
+			continue
+		}
+		keyIDOff := len(rest) - trailer
+		keyID := uint32(rest[keyIDOff])<<24 | uint32(rest[keyIDOff+1])<<16 | uint32(rest[keyIDOff+2])<<8 | uint32(rest[keyIDOff+3])
 
-func (m *Msg) unpackExtensionFields(b []byte) error {
-	//FIXME(yann): This dies horribly if a packet is malformed
-	i := 0
-	for i < len(b) {
-		// TODO(yann): verify and test this
-		t, l := b[i], int(b[i+1])
-		startVal := i + 2
-		endVal := l + startVal
-		val := b[startVal:endVal]
-
-		// Advance i of needed padding
-		padding := 4 - (l % 4)
-		if padding != 0 {
-			i += padding
+		key, gotAlgo, ok := ks.Lookup(keyID)
+		if !ok || gotAlgo != algo {
+			continue
 		}
-		e := ExtensionField{
-			Type:  t,
-			Value: val,
+
+		dgst := rest[keyIDOff+4:]
+		want := digest(algo, key, b[:HeaderSize+keyIDOff])
+		if subtle.ConstantTimeCompare(want, dgst) != 1 {
+			return ErrAuthFailed
 		}
-		m.ExtensionFields = append(m.ExtensionFields, e)
-	}
-	return nil
-}
 
-func (m *Msg) packExtensionFields(buf []byte) (msg []byte, err error) {
-	i := 0
-	for _, extensionField := range m.ExtensionFields {
-		l := extensionField.Len()
-		padding := int(4 - (l % 4))
-		buf[i] = extensionField.Type
-		buf[i+1] = l
-		startVal := i + 2
-		endVal := startVal + int(l)
-		copy(buf[startVal:endVal], extensionField.Value)
-		i = endVal + padding
+		m.KeyID = keyID
+		m.Dgst = append([]byte(nil), dgst...)
+		return m.unpackExtensionFields(rest[:keyIDOff])
 	}
-	return buf, nil
+	return ErrAuthFailed
 }
-
-//func NewClientMsg() ClientMsg
@@ -55,10 +55,10 @@ func TestTimestamp(t *testing.T) {
 		ts   Timestamp
 		err  error
 	}{
-		{NTPEpoch, Timestamp{0, 0}, nil},
-		{time.Date(1974, 04, 1, 15, 0, 0, 0, time.UTC), Timestamp{2343049200, 0}, nil},
-		{time.Date(1974, 04, 1, 15, 0, 0, 1, time.UTC), Timestamp{2343049200, 4}, nil},
-		{time.Date(2014, 10, 13, 14, 0, 0, 0, time.UTC), Timestamp{3622197600, 0}, nil},
+		{NTPEpoch, Timestamp{Seconds: 0, Fraction: 0}, nil},
+		{time.Date(1974, 04, 1, 15, 0, 0, 0, time.UTC), Timestamp{Seconds: 2343049200, Fraction: 0}, nil},
+		{time.Date(1974, 04, 1, 15, 0, 0, 1, time.UTC), Timestamp{Seconds: 2343049200, Fraction: 4}, nil},
+		{time.Date(2014, 10, 13, 14, 0, 0, 0, time.UTC), Timestamp{Seconds: 3622197600, Fraction: 0}, nil},
 	} {
 		s, err := NewTimestampFromTime(test.time)
 		if err != test.err {
@@ -67,12 +67,60 @@ func TestTimestamp(t *testing.T) {
 		if !matchTimestampWithPrecision(s, test.ts, 30) {
 			t.Errorf("%v and %v don't match", s, test.ts)
 		}
-		if tt := TimeFromTimestamp(s); !matchTimeApprox(tt, test.time) {
+		// NTPEpoch itself is outside the "now ± 68 years" window
+		// TimeFromTimestamp auto-disambiguates within, so round-trip
+		// through the era NewTimestampFromTime actually picked.
+		if tt := TimeFromTimestampInEra(s, s.Era); !matchTimeApprox(tt, test.time) {
 			t.Errorf("conversion from timestamp to Time didn't work: %s %s", tt, test.time)
 		}
 	}
 }
 
+func TestTimestampEra(t *testing.T) {
+	for _, test := range []struct {
+		time time.Time
+		era  int32
+	}{
+		{NTPEpoch, 0},
+		{time.Date(2014, 10, 13, 14, 0, 0, 0, time.UTC), 0},
+		{time.Date(2036, 2, 7, 6, 28, 16, 0, time.UTC), 1},
+		{time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC), -1},
+	} {
+		ts, err := NewTimestampFromTime(test.time)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.time, err)
+			continue
+		}
+		if ts.Era != test.era {
+			t.Errorf("%s: got era %d, expected %d", test.time, ts.Era, test.era)
+		}
+		if tt := TimeFromTimestampInEra(ts, ts.Era); !matchTimeApprox(tt, test.time) {
+			t.Errorf("conversion from timestamp to Time didn't round-trip: %s %s", tt, test.time)
+		}
+	}
+
+	// Explicit era selection lets us represent times well outside the
+	// "now ± 68 years" window that TimeFromTimestamp auto-selects for.
+	future := time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)
+	want, err := NewTimestampFromTime(future)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want.Era == 0 {
+		t.Fatalf("expected %s to fall outside era 0", future)
+	}
+	ts, err := NewTimestampFromTimeInEra(future, want.Era)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := TimeFromTimestampInEra(ts, want.Era); !matchTimeApprox(got, future) {
+		t.Errorf("era round-trip failed: %s, expected %s", got, future)
+	}
+	if _, err := NewTimestampFromTimeInEra(future, 0); err == nil {
+		t.Errorf("expected overflow error putting %s in era 0", future)
+	}
+}
+
 func TestTimestampPacking(t *testing.T) {
 	for _, ti := range []time.Time{
 		time.Now(),
@@ -0,0 +1,127 @@
+package ntp
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LeapSecond describes a single IERS leap-second event.
+type LeapSecond struct {
+	// Time is the UTC instant immediately following the leap second, e.g.
+	// 2017-01-01T00:00:00Z for the leap second inserted at the end of 2016.
+	Time time.Time
+
+	// Sign is +1 for an inserted (positive) leap second, the only kind
+	// observed so far, or -1 for a deleted (negative) one.
+	Sign int
+}
+
+// leapSeconds is the built-in table of historical leap seconds, kept sorted
+// by Time. It only goes back far enough to be useful for smearing recent
+// dates; RegisterLeapSecond extends it for announcements made after this was
+// written.
+var (
+	leapSecondsMu sync.Mutex
+	leapSeconds   = []LeapSecond{
+		{time.Date(2012, 7, 1, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), 1},
+	}
+)
+
+// RegisterLeapSecond adds a future (or otherwise missing) leap second to the
+// table SmearPolicy implementations consult. It's safe to call concurrently.
+func RegisterLeapSecond(at time.Time, sign int) {
+	leapSecondsMu.Lock()
+	defer leapSecondsMu.Unlock()
+	leapSeconds = append(leapSeconds, LeapSecond{at, sign})
+	sort.Slice(leapSeconds, func(i, j int) bool {
+		return leapSeconds[i].Time.Before(leapSeconds[j].Time)
+	})
+}
+
+func registeredLeapSeconds() []LeapSecond {
+	leapSecondsMu.Lock()
+	defer leapSecondsMu.Unlock()
+	return append([]LeapSecond(nil), leapSeconds...)
+}
+
+// SmearPolicy controls how a leap second is represented across a
+// NewTimestampFromTimeWith/TimeFromTimestampWith conversion. Instead of the
+// NTP clock jumping or repeating a second at the leap instant, a policy
+// spreads ("smears") the one-second correction over a window of time around
+// it, the way large NTP-consuming sites such as Google's public servers do.
+type SmearPolicy interface {
+	// Offset returns the adjustment smeared into t: NewTimestampFromTimeWith
+	// converts t.Add(Offset(t)) instead of t, and TimeFromTimestampWith
+	// undoes it on the way back out.
+	Offset(t time.Time) time.Duration
+}
+
+// NoSmear is the default, leap-second-naive behavior: no adjustment.
+var NoSmear SmearPolicy = noSmear{}
+
+type noSmear struct{}
+
+func (noSmear) Offset(time.Time) time.Duration { return 0 }
+
+// LinearSmear returns a SmearPolicy that ramps each leap second's one-second
+// correction linearly over the window immediately preceding it, so the ramp
+// finishes exactly at the leap instant.
+func LinearSmear(window time.Duration) SmearPolicy {
+	return windowedSmear{window: window, ease: func(p float64) float64 { return p }}
+}
+
+// CosineSmear is like LinearSmear, but eases in and out of the ramp using a
+// raised cosine, so the smeared clock's rate changes smoothly rather than
+// snapping to a constant slew at the window edges.
+func CosineSmear(window time.Duration) SmearPolicy {
+	return windowedSmear{window: window, ease: func(p float64) float64 {
+		return (1 - math.Cos(p*math.Pi)) / 2
+	}}
+}
+
+type windowedSmear struct {
+	window time.Duration
+	ease   func(progress float64) float64 // [0,1] -> [0,1]
+}
+
+// Offset returns the sum of every past leap second's full correction, plus
+// the in-progress fraction of whichever leap second's window t currently
+// falls within.
+func (w windowedSmear) Offset(t time.Time) time.Duration {
+	var total time.Duration
+	for _, leap := range registeredLeapSeconds() {
+		start := leap.Time.Add(-w.window)
+		switch {
+		case !t.Before(leap.Time):
+			total += time.Duration(leap.Sign) * time.Second
+		case t.After(start):
+			progress := float64(t.Sub(start)) / float64(w.window)
+			total += time.Duration(float64(leap.Sign) * w.ease(progress) * float64(time.Second))
+			return total // later leaps' windows haven't opened yet
+		default:
+			return total // leaps are sorted ascending; none of the rest apply yet
+		}
+	}
+	return total
+}
+
+// NewTimestampFromTimeWith converts t to its NTP Timestamp representation
+// like NewTimestampFromTime, but first smears t according to policy.
+func NewTimestampFromTimeWith(t time.Time, policy SmearPolicy) (Timestamp, error) {
+	return NewTimestampFromTime(t.Add(policy.Offset(t)))
+}
+
+// TimeFromTimestampWith is the inverse of NewTimestampFromTimeWith: it
+// un-smears ts according to policy. Since the smear ramp is gentle relative
+// to the one-second correction it spreads out, evaluating policy once at the
+// still-smeared estimate (rather than solving for the exact pre-image) is
+// accurate to a small fraction of the window and is the same approximation
+// real smearing NTP servers and clients make.
+func TimeFromTimestampWith(ts Timestamp, policy SmearPolicy) time.Time {
+	smeared := TimeFromTimestamp(ts)
+	return smeared.Add(-policy.Offset(smeared))
+}
@@ -0,0 +1,107 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoSmear(t *testing.T) {
+	for _, when := range []time.Time{
+		time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if off := NoSmear.Offset(when); off != 0 {
+			t.Errorf("NoSmear.Offset(%s) = %s, expected 0", when, off)
+		}
+	}
+}
+
+func TestLinearSmearOffset(t *testing.T) {
+	leap := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := 24 * time.Hour
+	policy := LinearSmear(window)
+
+	if off := policy.Offset(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); off != 0 {
+		t.Errorf("before all leaps: Offset = %s, expected 0", off)
+	}
+
+	// Before the 2017 leap's window opens, only the two earlier built-in
+	// leaps (2012, 2015) have fully applied.
+	before := leap.Add(-2 * window)
+	if off := policy.Offset(before); off != 2*time.Second {
+		t.Errorf("Offset(%s) = %s, expected 2s", before, off)
+	}
+
+	start := leap.Add(-window)
+	if off := policy.Offset(start); off != 2*time.Second {
+		t.Errorf("Offset(window start) = %s, expected 2s", off)
+	}
+
+	mid := leap.Add(-window / 2)
+	if off := policy.Offset(mid); off != 2500*time.Millisecond {
+		t.Errorf("Offset(window midpoint) = %s, expected 2.5s", off)
+	}
+
+	if off := policy.Offset(leap); off != 3*time.Second {
+		t.Errorf("Offset(leap instant) = %s, expected 3s", off)
+	}
+
+	after := leap.Add(time.Hour)
+	if off := policy.Offset(after); off != 3*time.Second {
+		t.Errorf("Offset(after leap) = %s, expected 3s", off)
+	}
+}
+
+func TestCosineSmearDiffersFromLinear(t *testing.T) {
+	leap := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := 24 * time.Hour
+	quarter := leap.Add(-window + window/4)
+
+	linear := LinearSmear(window).Offset(quarter)
+	cosine := CosineSmear(window).Offset(quarter)
+	if linear == cosine {
+		t.Errorf("expected cosine and linear smear to differ away from the midpoint, both got %s", linear)
+	}
+}
+
+func TestRegisterLeapSecond(t *testing.T) {
+	orig := registeredLeapSeconds()
+	t.Cleanup(func() {
+		leapSecondsMu.Lock()
+		leapSeconds = orig
+		leapSecondsMu.Unlock()
+	})
+
+	future := time.Date(2400, 1, 1, 0, 0, 0, 0, time.UTC)
+	RegisterLeapSecond(future, 1)
+
+	policy := LinearSmear(24 * time.Hour)
+	if off := policy.Offset(future.Add(time.Hour)); off != 4*time.Second {
+		t.Errorf("Offset after registering a new leap second = %s, expected 4s", off)
+	}
+}
+
+func TestTimestampSmearRoundTrip(t *testing.T) {
+	leap := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := CosineSmear(24 * time.Hour)
+
+	for _, when := range []time.Time{
+		leap.Add(-30 * time.Hour),
+		leap.Add(-12 * time.Hour),
+		leap,
+		leap.Add(12 * time.Hour),
+	} {
+		ts, err := NewTimestampFromTimeWith(when, policy)
+		if err != nil {
+			t.Fatalf("NewTimestampFromTimeWith(%s): %s", when, err)
+		}
+		got := TimeFromTimestampWith(ts, policy)
+		// TimeFromTimestampWith approximates the inverse (see its doc
+		// comment), so allow slack well below the one-second smear
+		// itself rather than the tight tolerance used for exact
+		// round-trips elsewhere.
+		if d := got.Sub(when); d > time.Millisecond || d < -time.Millisecond {
+			t.Errorf("round-trip for %s: got %s, diff %s", when, got, d)
+		}
+	}
+}